@@ -0,0 +1,150 @@
+// See license file for copyright and license details.
+
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// offerDir streams dir as a tar or zip archive, so that a whole directory
+// tree can be shared in one command without a pre-packaging step.
+func offerDir(dir, archive, passphrase string) http.HandlerFunc {
+	name := filepath.Base(filepath.Clean(dir))
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			writeStatusPage(w, http.StatusMethodNotAllowed)
+			return
+		}
+
+		ext := ".tar"
+		if archive == "zip" {
+			ext = ".zip"
+		}
+		w.Header().Set("Content-Disposition", "attachment; filename="+name+ext)
+
+		writeArchive := writeTar
+		if archive == "zip" {
+			writeArchive = writeZip
+		}
+
+		if passphrase == "" {
+			if archive == "zip" {
+				w.Header().Set("Content-Type", "application/zip")
+			} else {
+				w.Header().Set("Content-Type", "application/x-tar")
+			}
+			if err := writeArchive(w, dir); err != nil {
+				printError(err)
+			}
+			return
+		}
+
+		// the archive's own content type doesn't matter anymore once
+		// it's wrapped in a sealed stream.
+		w.Header().Set("Content-Type", "application/octet-stream")
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(writeArchive(pw, dir))
+		}()
+		if err := encryptStream(w, pr, passphrase); err != nil {
+			printError(err)
+		}
+	}
+}
+
+// writeTar walks dir and writes its contents as a tar stream to w. tar is
+// the default archive format; like writeZip, it never seeks and so is
+// streamed straight into the response body.
+func writeTar(w io.Writer, dir string) error {
+	tw := tar.NewWriter(w)
+	defer tw.Close()
+
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if d.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+}
+
+// writeZip walks dir and writes its contents as a zip stream to w, using
+// the STORE method since the payload is sent over the LAN and compressing
+// it just burns CPU for no real gain.
+func writeZip(w io.Writer, dir string) error {
+	zw := zip.NewWriter(w)
+	defer zw.Close()
+
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		hdr, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		hdr.Method = zip.Store
+		if d.IsDir() {
+			hdr.Name += "/"
+			_, err := zw.CreateHeader(hdr)
+			return err
+		}
+
+		fw, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(fw, f)
+		return err
+	})
+}
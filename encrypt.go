@@ -0,0 +1,164 @@
+// See license file for copyright and license details.
+
+package main
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	encMagic       = "OFR1"
+	encSaltLen     = 16
+	encNoncePfxLen = 8
+	encChunkSize   = 64 * 1024
+	encKeyLen      = 32
+)
+
+var errBadStream = errors.New("malformed or truncated encrypted stream")
+
+// encryptStream wraps src in AES-256-GCM sealed chunks written to dst,
+// prefixed by a small header (magic, salt, nonce prefix, chunk size) so
+// the key can be rederived and the stream processed without ever
+// deferring authentication to EOF.
+func encryptStream(dst io.Writer, src io.Reader, passphrase string) error {
+	salt := make([]byte, encSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	noncePfx := make([]byte, encNoncePfxLen)
+	if _, err := rand.Read(noncePfx); err != nil {
+		return err
+	}
+
+	gcm, err := newGCM(passphrase, salt)
+	if err != nil {
+		return err
+	}
+
+	if _, err := dst.Write([]byte(encMagic)); err != nil {
+		return err
+	}
+	if _, err := dst.Write(salt); err != nil {
+		return err
+	}
+	if _, err := dst.Write(noncePfx); err != nil {
+		return err
+	}
+	if err := binary.Write(dst, binary.BigEndian, uint32(encChunkSize)); err != nil {
+		return err
+	}
+
+	nonce := make([]byte, len(noncePfx)+4)
+	copy(nonce, noncePfx)
+
+	buf := make([]byte, encChunkSize)
+	var counter uint32
+	for {
+		n, err := io.ReadFull(src, buf)
+		final := errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+		if err != nil && !final {
+			return err
+		}
+
+		binary.BigEndian.PutUint32(nonce[len(noncePfx):], counter)
+		aad := []byte{0}
+		if final {
+			aad[0] = 1
+		}
+		sealed := gcm.Seal(nil, nonce, buf[:n], aad)
+
+		if err := binary.Write(dst, binary.BigEndian, uint32(len(sealed))); err != nil {
+			return err
+		}
+		if _, err := dst.Write(sealed); err != nil {
+			return err
+		}
+		counter++
+
+		if final {
+			return nil
+		}
+	}
+}
+
+// decryptStream reverses encryptStream, verifying every chunk's GCM tag
+// and rejecting the stream if it ends before a chunk authenticated as
+// final, which catches truncation.
+func decryptStream(dst io.Writer, src io.Reader, passphrase string) error {
+	br := bufio.NewReader(src)
+
+	magic := make([]byte, len(encMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != encMagic {
+		return errBadStream
+	}
+	salt := make([]byte, encSaltLen)
+	if _, err := io.ReadFull(br, salt); err != nil {
+		return errBadStream
+	}
+	noncePfx := make([]byte, encNoncePfxLen)
+	if _, err := io.ReadFull(br, noncePfx); err != nil {
+		return errBadStream
+	}
+	var chunkSize uint32
+	if err := binary.Read(br, binary.BigEndian, &chunkSize); err != nil {
+		return errBadStream
+	}
+
+	gcm, err := newGCM(passphrase, salt)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, len(noncePfx)+4)
+	copy(nonce, noncePfx)
+
+	var counter uint32
+	for {
+		var n uint32
+		if err := binary.Read(br, binary.BigEndian, &n); err != nil {
+			return errBadStream
+		}
+		sealed := make([]byte, n)
+		if _, err := io.ReadFull(br, sealed); err != nil {
+			return errBadStream
+		}
+
+		_, peekErr := br.Peek(1)
+		final := errors.Is(peekErr, io.EOF)
+		aad := []byte{0}
+		if final {
+			aad[0] = 1
+		}
+
+		binary.BigEndian.PutUint32(nonce[len(noncePfx):], counter)
+		plain, err := gcm.Open(nil, nonce, sealed, aad)
+		if err != nil {
+			return errBadStream
+		}
+		if _, err := dst.Write(plain); err != nil {
+			return err
+		}
+		counter++
+
+		if final {
+			return nil
+		}
+	}
+}
+
+func newGCM(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key := argon2.IDKey([]byte(passphrase), salt, 1, 64*1024, 4, encKeyLen)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -4,6 +4,7 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	_ "embed"
 	"errors"
 	"flag"
@@ -27,8 +28,25 @@ func main() {
 	flagPort := flag.Uint("p", 8080, "server port")
 	flagReceive := flag.Bool("r", false, "receive mode")
 	flagUrl := flag.Bool("u", false, "print URL after server starts listening")
+	flagSecure := flag.Bool("s", false, "use HTTPS, generating a self-signed certificate unless -cert/-key are given")
+	flagCert := flag.String("cert", "", "TLS certificate file, use together with -key")
+	flagKey := flag.String("key", "", "TLS key file, use together with -cert")
+	flagArchive := flag.String("a", "tar", "archive format used to offer a directory: tar or zip")
+	flagQR := flag.Bool("q", false, "print the URL as a QR code on stderr")
+	flagEnc := flag.String("e", "", "encrypt/decrypt the transfer with this passphrase (AES-256-GCM)")
+	flagDecrypt := flag.Bool("d", false, "decrypt a stream from stdin to stdout using -e, instead of serving")
 	flag.Parse()
 
+	if *flagDecrypt {
+		if *flagEnc == "" {
+			die("-d requires -e <passphrase>")
+		}
+		if err := decryptStream(os.Stdout, os.Stdin, *flagEnc); err != nil {
+			die(err.Error())
+		}
+		return
+	}
+
 	if flag.NArg() > 1 {
 		die("too many files, use zip or tar")
 	}
@@ -41,13 +59,19 @@ func main() {
 	done := make(chan bool)
 	var handler http.HandlerFunc
 	if *flagReceive {
-		if *flagNReqs > 1 {
-			die("can't receive more than one file, use zip or tar")
-		}
 		if *flagFname != "" {
 			fpath = *flagFname
 		}
-		handler = limitReqs("POST", 1, done, receive(fpath))
+		multiDir := *flagNReqs > 1
+		if fpath != "-" {
+			if fi, err := os.Stat(fpath); err == nil && fi.IsDir() {
+				multiDir = true
+			}
+		}
+		if multiDir && fpath == "-" {
+			die("can't receive more than one file into stdin, give a directory")
+		}
+		handler = limitReqs("POST", *flagNReqs, done, receive(fpath, *flagEnc, multiDir))
 	} else {
 		if fpath == "-" && *flagNReqs > 1 {
 			die("can't offer stdin more than once")
@@ -58,7 +82,14 @@ func main() {
 		if *flagFname != "" {
 			*flagFname = filepath.Base(*flagFname)
 		}
-		handler = limitReqs("GET", *flagNReqs, done, offer(fpath, *flagFname))
+		if fi, err := os.Stat(fpath); fpath != "-" && err == nil && fi.IsDir() {
+			if *flagArchive != "tar" && *flagArchive != "zip" {
+				die("invalid archive format " + *flagArchive + ", use tar or zip")
+			}
+			handler = limitReqs("GET", *flagNReqs, done, offerDir(fpath, *flagArchive, *flagEnc))
+		} else {
+			handler = limitReqs("GET", *flagNReqs, done, offer(fpath, *flagFname, *flagEnc))
+		}
 	}
 
 	http.HandleFunc("/", handler)
@@ -68,8 +99,25 @@ func main() {
 	if err != nil {
 		die(err.Error())
 	}
-	if *flagUrl {
-		printURL(ln.Addr().(*net.TCPAddr).Port)
+
+	if *flagSecure {
+		cert, err := loadOrGenCert(*flagCert, *flagKey, ln.Addr().(*net.TCPAddr).Port)
+		if err != nil {
+			die(err.Error())
+		}
+		srv.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+		if *flagUrl {
+			fmt.Fprintf(os.Stderr, "certificate SHA-256 fingerprint: %s\n", certFingerprint(cert))
+		}
+	}
+	if *flagUrl || *flagQR {
+		url := buildURL(ln.Addr().(*net.TCPAddr).Port, *flagSecure)
+		if *flagUrl {
+			fmt.Fprintln(os.Stderr, url) // don't pollute stdout
+		}
+		if *flagQR {
+			printQR(url)
+		}
 	}
 
 	sig := make(chan os.Signal, 1)
@@ -84,7 +132,13 @@ func main() {
 		}
 		done <- true
 	}()
-	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+
+	if *flagSecure {
+		err = srv.ServeTLS(ln, "", "")
+	} else {
+		err = srv.Serve(ln)
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		die(err.Error())
 	}
 	<-done
@@ -99,14 +153,28 @@ func printError(err error) {
 	fmt.Fprintf(os.Stderr, "error: %s\n", err)
 }
 
-func printURL(port int) {
+func buildURL(port int, secure bool) string {
 	host := "localhost"
+	if ip := localIP(); ip != nil {
+		host = ip.String()
+	}
+	scheme := "http"
+	if secure {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s:%d", scheme, host, port)
+}
+
+// localIP returns this host's LAN IP address, or nil if it can't be
+// determined (e.g. no network connectivity).
+func localIP() net.IP {
 	// https://stackoverflow.com/a/37382208/13527856
-	if conn, err := net.Dial("udp", "255.255.255.255:99"); err == nil {
-		host = conn.LocalAddr().(*net.UDPAddr).IP.String()
-		defer conn.Close()
+	conn, err := net.Dial("udp", "255.255.255.255:99")
+	if err != nil {
+		return nil
 	}
-	fmt.Fprintf(os.Stderr, "http://%s:%d\n", host, port) // don't pollute stdout
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP
 }
 
 func writeStatusPage(w http.ResponseWriter, status int) {
@@ -139,36 +207,74 @@ func limitReqs(method string, n uint, done chan bool, next http.HandlerFunc) htt
 	}
 }
 
-func offer(fpath, fname string) http.HandlerFunc {
+func offer(fpath, fname, passphrase string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "GET" {
 			writeStatusPage(w, http.StatusMethodNotAllowed)
 			return
 		}
 
-		f := os.Stdin
-		if fpath != "-" {
-			var err error
-			f, err = os.Open(fpath)
-			if err != nil {
+		if fname != "" {
+			w.Header().Add("Content-Disposition", "attachment; filename="+fname)
+		}
+
+		if fpath == "-" {
+			// stdin can't be seeked, so it doesn't support ranges.
+			if err := copyOrEncrypt(w, os.Stdin, passphrase); err != nil {
 				printError(err)
-				writeStatusPage(w, http.StatusInternalServerError)
-				return
 			}
+			return
+		}
+
+		f, err := os.Open(fpath)
+		if err != nil {
+			printError(err)
+			writeStatusPage(w, http.StatusInternalServerError)
+			return
 		}
 		defer f.Close()
 
-		if fname != "" {
-			w.Header().Add("Content-Disposition", "attachment; filename="+fname)
+		if passphrase != "" {
+			// encryption turns the body into an opaque sealed stream, so
+			// there's no ranges/conditional GET to serve anymore.
+			if err := encryptStream(w, f, passphrase); err != nil {
+				printError(err)
+			}
+			return
 		}
-		if _, err := io.Copy(w, f); err != nil {
+
+		fi, err := f.Stat()
+		if err != nil {
 			printError(err)
+			writeStatusPage(w, http.StatusInternalServerError)
 			return
 		}
+
+		name := fname
+		if name == "" {
+			name = filepath.Base(fpath)
+		}
+		// http.ServeContent gives us Accept-Ranges, ETag/Last-Modified and
+		// 206 Partial Content handling for free, so offers survive flaky
+		// Wi-Fi with "curl -C -" or "wget -c". ServeContent only checks
+		// ETags, so derive one from the file's modification time and size.
+		w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, fi.ModTime().UnixNano(), fi.Size()))
+		http.ServeContent(w, r, name, fi.ModTime(), f)
 	}
 }
 
-func receive(fpath string) http.HandlerFunc {
+// copyOrEncrypt streams src to dst, sealing it with passphrase if given.
+func copyOrEncrypt(dst io.Writer, src io.Reader, passphrase string) error {
+	if passphrase != "" {
+		return encryptStream(dst, src, passphrase)
+	}
+	_, err := io.Copy(dst, src)
+	return err
+}
+
+func receive(fpath, passphrase string, multiDir bool) http.HandlerFunc {
+	var mu sync.Mutex
+	var seq int
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == "GET" {
 			w.Write(uploadPage)
@@ -186,17 +292,27 @@ func receive(fpath string) http.HandlerFunc {
 			return
 		}
 
-		f := os.Stdout
-		if fpath != "-" {
-			var err error
-			f, err = os.Create(fpath)
-			if err != nil {
+		if multiDir {
+			if err := os.MkdirAll(fpath, 0777); err != nil {
 				printError(err)
 				writeStatusPage(w, http.StatusInternalServerError)
 				return
 			}
 		}
-		defer f.Close()
+
+		var f *os.File
+		if !multiDir {
+			f = os.Stdout
+			if fpath != "-" {
+				f, err = os.Create(fpath)
+				if err != nil {
+					printError(err)
+					writeStatusPage(w, http.StatusInternalServerError)
+					return
+				}
+			}
+			defer f.Close()
+		}
 
 		for {
 			part, err := mr.NextPart()
@@ -209,7 +325,32 @@ func receive(fpath string) http.HandlerFunc {
 				writeStatusPage(w, http.StatusBadRequest)
 				return
 			}
-			if _, err := io.Copy(f, part); err != nil {
+
+			if multiDir {
+				name := sanitizeFileName(part.FileName())
+				if name == "" {
+					mu.Lock()
+					seq++
+					name = fmt.Sprintf("file%d", seq)
+					mu.Unlock()
+				}
+				f, err = os.Create(filepath.Join(fpath, name))
+				if err != nil {
+					printError(err)
+					writeStatusPage(w, http.StatusInternalServerError)
+					return
+				}
+			}
+
+			if passphrase != "" {
+				err = decryptStream(f, part, passphrase)
+			} else {
+				_, err = io.Copy(f, part)
+			}
+			if multiDir {
+				f.Close()
+			}
+			if err != nil {
 				printError(err)
 				writeStatusPage(w, http.StatusInternalServerError)
 				return
@@ -217,3 +358,13 @@ func receive(fpath string) http.HandlerFunc {
 		}
 	}
 }
+
+// sanitizeFileName returns a safe base name for a received file, or ""
+// if name is empty or would escape the destination directory.
+func sanitizeFileName(name string) string {
+	name = filepath.Base(name)
+	if name == "" || name == "." || name == ".." || name == string(filepath.Separator) {
+		return ""
+	}
+	return name
+}
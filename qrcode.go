@@ -0,0 +1,378 @@
+// See license file for copyright and license details.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// errQRTooBig means the data doesn't fit in a version 1-4 QR code at the
+// low error correction level, which is all this minimal encoder supports.
+var errQRTooBig = errors.New("data too big for QR code")
+
+// qrVersion describes the byte-mode capacity of a QR version at the low
+// (L) error correction level, the only level this encoder implements.
+type qrVersion struct {
+	dataCodewords int
+	ecCodewords   int
+}
+
+// versions 1 through 4 comfortably fit a LAN URL such as
+// "https://192.168.1.42:8080" (a few dozen bytes).
+var qrVersions = []qrVersion{
+	{dataCodewords: 19, ecCodewords: 7},
+	{dataCodewords: 34, ecCodewords: 10},
+	{dataCodewords: 55, ecCodewords: 15},
+	{dataCodewords: 80, ecCodewords: 20},
+}
+
+// printQR renders s as a QR code to stderr using half-block Unicode
+// characters, so it can be scanned straight from a terminal by a phone
+// camera without requiring any third-party dependency.
+func printQR(s string) {
+	modules, err := encodeQR([]byte(s))
+	if err != nil {
+		printError(err)
+		return
+	}
+	writeQR(os.Stderr, modules)
+}
+
+// encodeQR builds the module matrix for data using byte mode, the lowest
+// error correction level and mask pattern 0.
+func encodeQR(data []byte) ([][]bool, error) {
+	ver := -1
+	for i, v := range qrVersions {
+		if 12+8*len(data) <= v.dataCodewords*8 {
+			ver = i + 1
+			break
+		}
+	}
+	if ver == -1 {
+		return nil, errQRTooBig
+	}
+	v := qrVersions[ver-1]
+
+	codewords := qrEncodeData(data, v.dataCodewords)
+	ecc := rsEncode(codewords, v.ecCodewords)
+	codewords = append(codewords, ecc...)
+
+	return qrBuildMatrix(ver, codewords), nil
+}
+
+// qrEncodeData builds the data codeword sequence: mode indicator, 8-bit
+// character count, the raw bytes, a terminator and padding up to
+// dataCodewords.
+func qrEncodeData(data []byte, dataCodewords int) []byte {
+	bw := newBitWriter()
+	bw.writeBits(0b0100, 4) // byte mode
+	bw.writeBits(uint32(len(data)), 8)
+	for _, b := range data {
+		bw.writeBits(uint32(b), 8)
+	}
+
+	capacity := dataCodewords * 8
+	if pad := capacity - bw.len(); pad > 0 {
+		if pad > 4 {
+			pad = 4
+		}
+		bw.writeBits(0, pad)
+	}
+	for bw.len()%8 != 0 {
+		bw.writeBits(0, 1)
+	}
+
+	codewords := bw.bytes()
+	for i := 0; len(codewords) < dataCodewords; i++ {
+		if i%2 == 0 {
+			codewords = append(codewords, 0xec)
+		} else {
+			codewords = append(codewords, 0x11)
+		}
+	}
+	return codewords
+}
+
+type bitWriter struct {
+	bits []bool
+}
+
+func newBitWriter() *bitWriter { return &bitWriter{} }
+
+func (bw *bitWriter) writeBits(val uint32, n int) {
+	for i := n - 1; i >= 0; i-- {
+		bw.bits = append(bw.bits, (val>>uint(i))&1 != 0)
+	}
+}
+
+func (bw *bitWriter) len() int { return len(bw.bits) }
+
+func (bw *bitWriter) bytes() []byte {
+	out := make([]byte, len(bw.bits)/8)
+	for i, bit := range bw.bits {
+		if bit {
+			out[i/8] |= 1 << uint(7-i%8)
+		}
+	}
+	return out
+}
+
+// qrBuildMatrix lays out the finder, timing and alignment patterns, the
+// format information and the masked data codewords for the given version.
+func qrBuildMatrix(ver int, codewords []byte) [][]bool {
+	size := ver*4 + 17
+	modules := make([][]bool, size)
+	isFunc := make([][]bool, size)
+	for i := range modules {
+		modules[i] = make([]bool, size)
+		isFunc[i] = make([]bool, size)
+	}
+
+	set := func(x, y int, dark bool) {
+		modules[y][x] = dark
+		isFunc[y][x] = true
+	}
+
+	drawFinder := func(cx, cy int) {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := cx+dx, cy+dy
+				if x < 0 || x >= size || y < 0 || y >= size {
+					continue
+				}
+				dist := abs(dx)
+				if abs(dy) > dist {
+					dist = abs(dy)
+				}
+				set(x, y, dist != 2 && dist != 4)
+			}
+		}
+	}
+	drawFinder(3, 3)
+	drawFinder(size-4, 3)
+	drawFinder(3, size-4)
+
+	for i := 8; i < size-8; i++ {
+		dark := i%2 == 0
+		set(i, 6, dark)
+		set(6, i, dark)
+	}
+
+	for _, cx := range qrAlignmentCenters(ver) {
+		for _, cy := range qrAlignmentCenters(ver) {
+			if isFunc[cy][cx] {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					dist := abs(dx)
+					if abs(dy) > dist {
+						dist = abs(dy)
+					}
+					set(cx+dx, cy+dy, dist != 1)
+				}
+			}
+		}
+	}
+
+	// reserve the format information areas, filled in by drawFormatBits.
+	for i := 0; i <= 8; i++ {
+		if !isFunc[8][i] {
+			set(i, 8, false)
+		}
+		if !isFunc[i][8] {
+			set(8, i, false)
+		}
+	}
+	for i := 0; i < 8; i++ {
+		set(size-1-i, 8, false)
+		set(8, size-1-i, false)
+	}
+
+	qrDrawCodewords(modules, isFunc, codewords)
+	qrDrawFormatBits(modules, size)
+
+	return modules
+}
+
+// qrAlignmentCenters returns the row/column coordinates where alignment
+// pattern centers may be placed, following the formula used by the
+// reference QR implementation for versions where only one extra center
+// (besides 6) is needed.
+func qrAlignmentCenters(ver int) []int {
+	if ver == 1 {
+		return nil
+	}
+	size := ver*4 + 17
+	return []int{6, size - 7}
+}
+
+// qrDrawCodewords places the data+EC codewords into the non-function
+// modules following the standard zigzag column order, applying mask
+// pattern 0 ((row+col)%2==0) as it goes.
+func qrDrawCodewords(modules, isFunc [][]bool, codewords []byte) {
+	size := len(modules)
+	bitLen := len(codewords) * 8
+	i := 0
+	for right := size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := ((right + 1) & 2) == 0
+		for vert := 0; vert < size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if upward {
+					y = size - 1 - vert
+				}
+				if isFunc[y][x] {
+					continue
+				}
+				var bit bool
+				if i < bitLen {
+					bit = (codewords[i/8]>>uint(7-i%8))&1 != 0
+				}
+				i++
+				if (x+y)%2 == 0 {
+					bit = !bit
+				}
+				modules[y][x] = bit
+			}
+		}
+	}
+}
+
+// qrDrawFormatBits computes and places the 15-bit format information
+// (error correction level L, mask pattern 0) in its two reserved copies.
+func qrDrawFormatBits(modules [][]bool, size int) {
+	const eccL = 0b01
+	data := eccL<<3 | 0 // mask pattern 0
+
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem>>9)&1)*0x537
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+
+	get := func(i int) bool { return (bits>>uint(i))&1 != 0 }
+
+	for i := 0; i <= 5; i++ {
+		modules[i][8] = get(i)
+	}
+	modules[7][8] = get(6)
+	modules[8][8] = get(7)
+	modules[8][7] = get(8)
+	for i := 9; i < 15; i++ {
+		modules[8][14-i] = get(i)
+	}
+
+	for i := 0; i < 8; i++ {
+		modules[8][size-1-i] = get(i)
+	}
+	for i := 8; i < 15; i++ {
+		modules[size-15+i][8] = get(i)
+	}
+
+	modules[size-8][8] = true // dark module
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// gfExp and gfLog are GF(256) exponentiation/logarithm tables for the
+// QR code's primitive polynomial x^8+x^4+x^3+x^2+1 (0x11d), used by
+// rsEncode to compute the Reed-Solomon error correction codewords.
+var gfExp [256]byte
+var gfLog [256]byte
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfLog[byte(x)] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[(int(gfLog[a])+int(gfLog[b]))%255]
+}
+
+// rsGeneratorPoly returns the Reed-Solomon generator polynomial of the
+// given degree, coefficients ordered from highest to lowest degree.
+func rsGeneratorPoly(degree int) []byte {
+	poly := []byte{1}
+	for i := 0; i < degree; i++ {
+		next := make([]byte, len(poly)+1)
+		for j, c := range poly {
+			next[j] ^= c
+			next[j+1] ^= gfMul(c, gfExp[i])
+		}
+		poly = next
+	}
+	return poly
+}
+
+// rsEncode returns the eccLen Reed-Solomon error correction codewords for
+// data.
+func rsEncode(data []byte, eccLen int) []byte {
+	gen := rsGeneratorPoly(eccLen)
+	res := make([]byte, len(data)+eccLen)
+	copy(res, data)
+	for i := 0; i < len(data); i++ {
+		coef := res[i]
+		if coef == 0 {
+			continue
+		}
+		for j, g := range gen {
+			res[i+j] ^= gfMul(g, coef)
+		}
+	}
+	return res[len(data):]
+}
+
+// writeQR renders modules to w using half-block Unicode characters, two
+// matrix rows per terminal line, with a 4-module quiet zone border.
+func writeQR(w io.Writer, modules [][]bool) {
+	const quiet = 4
+	size := len(modules)
+	get := func(x, y int) bool {
+		if x < 0 || y < 0 || x >= size || y >= size {
+			return false
+		}
+		return modules[y][x]
+	}
+
+	for y := -quiet; y < size+quiet; y += 2 {
+		var sb strings.Builder
+		for x := -quiet; x < size+quiet; x++ {
+			top, bottom := get(x, y), get(x, y+1)
+			switch {
+			case top && bottom:
+				sb.WriteRune('█')
+			case top:
+				sb.WriteRune('▀')
+			case bottom:
+				sb.WriteRune('▄')
+			default:
+				sb.WriteRune(' ')
+			}
+		}
+		fmt.Fprintln(w, sb.String())
+	}
+}